@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitUploader 借助 Gitee/GitHub 的 Contents API 把文件提交为仓库内的一个 commit
+type gitUploader struct {
+	config GitConfig
+	client *http.Client
+}
+
+func newGitUploader(cfg GitConfig) *gitUploader {
+	return &gitUploader{config: cfg, client: &http.Client{}}
+}
+
+// gitContentsRequest 对应 Contents API 的请求体，Gitee/GitHub 字段基本一致
+type gitContentsRequest struct {
+	Message string `json:"message"`
+	Content string `json:"content"`
+	Branch  string `json:"branch,omitempty"`
+	// Gitee 使用 access_token 字段鉴权，GitHub 走 Authorization 头，两者都保留以兼容
+	AccessToken string `json:"access_token,omitempty"`
+}
+
+// gitContentsResponse 仅解析我们需要的返回字段
+type gitContentsResponse struct {
+	Content struct {
+		DownloadURL string `json:"download_url"`
+	} `json:"content"`
+	// Gitee 成功创建文件时，download_url 在顶层的 content 字段里，结构与 GitHub 一致，
+	// 但部分 Gitee 版本把其放在顶层，做一次兜底解析
+	RawDownloadURL string `json:"download_url"`
+}
+
+// Upload 将文件 base64 编码后 PUT 到 /repos/{owner}/{repo}/contents/{path}
+func (u *gitUploader) Upload(ctx context.Context, imagePath string) (string, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	filename := filepath.Base(imagePath)
+	repoPath := filename
+	if u.config.Path != "" {
+		repoPath = strings.TrimSuffix(u.config.Path, "/") + "/" + filename
+	}
+
+	reqBody := gitContentsRequest{
+		Message:     fmt.Sprintf("upload %s", filename),
+		Content:     base64.StdEncoding.EncodeToString(data),
+		Branch:      u.config.Branch,
+		AccessToken: u.config.Token,
+	}
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request body: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", u.apiBase(), u.config.Owner, u.config.Repo, repoPath)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if u.config.Provider != "gitee" {
+		req.Header.Set("Authorization", "Bearer "+u.config.Token)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", newStatusError(resp.StatusCode, fmt.Errorf("upload failed: %s", string(respBody)), resp.Header)
+	}
+
+	var parsed gitContentsResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		// 这个点上 commit 已经推送成功了，重试只会再推送一次重复的 commit，必须标成终态错误
+		return "", newTerminalError(fmt.Errorf("failed to parse response: %v", err))
+	}
+
+	downloadURL := parsed.Content.DownloadURL
+	if downloadURL == "" {
+		downloadURL = parsed.RawDownloadURL
+	}
+	if downloadURL == "" {
+		// 同样，commit 已经推送成功，只是返回体里没带上 download_url，重试没有意义
+		return "", newTerminalError(fmt.Errorf("upload succeeded but no download_url was returned"))
+	}
+	return downloadURL, nil
+}
+
+// apiBase 返回 Contents API 的根地址，允许自建 Gitea/GitHub Enterprise 覆盖
+func (u *gitUploader) apiBase() string {
+	if u.config.APIBase != "" {
+		return strings.TrimSuffix(u.config.APIBase, "/")
+	}
+	if u.config.Provider == "gitee" {
+		return "https://gitee.com/api/v5"
+	}
+	return "https://api.github.com"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ApiResponse API响应结构体
+type ApiResponse struct {
+	Status int    `json:"status"`
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Data   string `json:"data"`
+}
+
+// httpUploader 通过通用 HTTP 表单接口上传图片，这是原先 uploadImage 的实现
+type httpUploader struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+func newHTTPUploader(config HTTPConfig) *httpUploader {
+	return &httpUploader{config: config, client: &http.Client{}}
+}
+
+// Upload 上传单个图片到服务器
+func (u *httpUploader) Upload(ctx context.Context, imagePath string) (string, error) {
+	// 打开图片文件
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+	defer file.Close()
+
+	// 创建multipart表单
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	// 获取文件名
+	filename := filepath.Base(imagePath)
+
+	// 获取文件扩展名并设置Content-Type
+	contentType := getContentType(imagePath)
+
+	// 创建表单文件部分
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %v", err)
+	}
+
+	// 复制文件内容
+	_, err = io.Copy(part, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy file content: %v", err)
+	}
+
+	// 添加headers
+	headers := fmt.Sprintf("Content-Type: %s", contentType)
+	writer.WriteField("headers", headers)
+	writer.Close()
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(ctx, "POST", u.config.APIUrl, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// 设置Basic Auth和Content-Type
+	req.SetBasicAuth(u.config.Username, u.config.Password)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	// 发送请求
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 解析响应
+	return parseResponse(resp)
+}
+
+// getContentType 根据文件扩展名获取Content-Type
+func getContentType(imagePath string) string {
+	ext := strings.ToLower(filepath.Ext(imagePath))
+	if ext != "" && ext[0] == '.' {
+		ext = ext[1:]
+	}
+
+	switch ext {
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "svg":
+		return "image/svg+xml"
+	case "webp":
+		return "image/webp"
+	default:
+		return "image/" + ext
+	}
+}
+
+// parseResponse 解析API响应
+func parseResponse(resp *http.Response) (string, error) {
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// 解析JSON响应；请求已经成功发出，解析失败重试也不会有不同结果，标成终态错误
+	var apiResp ApiResponse
+	err = json.Unmarshal(respBody, &apiResp)
+	if err != nil {
+		return "", newTerminalError(fmt.Errorf("failed to parse response: %v", err))
+	}
+
+	// 检查状态码；接口把业务状态码放在响应体里，传输层状态码异常时以传输层为准
+	effectiveStatus := resp.StatusCode
+	if effectiveStatus == http.StatusOK && apiResp.Status != http.StatusOK {
+		effectiveStatus = apiResp.Status
+	}
+	if effectiveStatus != http.StatusOK || apiResp.Code != 1 {
+		return "", newStatusError(effectiveStatus, fmt.Errorf("upload failed: %s", apiResp.Msg), resp.Header)
+	}
+
+	return apiResp.Data, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/qiniu/go-sdk/v7/auth/qbox"
+	"github.com/qiniu/go-sdk/v7/storage"
+)
+
+// qiniuUploader 上传到七牛云对象存储
+type qiniuUploader struct {
+	config QiniuConfig
+	mac    *qbox.Mac
+}
+
+func newQiniuUploader(cfg QiniuConfig) *qiniuUploader {
+	return &qiniuUploader{
+		config: cfg,
+		mac:    qbox.NewMac(cfg.AccessKey, cfg.SecretKey),
+	}
+}
+
+// Upload 生成上传凭证后将文件上传至七牛 bucket，返回绑定域名下的访问 URL
+func (u *qiniuUploader) Upload(ctx context.Context, imagePath string) (string, error) {
+	key := filepath.Base(imagePath)
+	if u.config.KeyPrefix != "" {
+		key = strings.TrimSuffix(u.config.KeyPrefix, "/") + "/" + key
+	}
+
+	putPolicy := storage.PutPolicy{
+		Scope: fmt.Sprintf("%s:%s", u.config.Bucket, key),
+	}
+	upToken := putPolicy.UploadToken(u.mac)
+
+	cfg := storage.Config{}
+	formUploader := storage.NewFormUploader(&cfg)
+	ret := storage.PutRet{}
+
+	err := formUploader.PutFile(ctx, &ret, upToken, key, imagePath, nil)
+	if err != nil {
+		return "", classifyQiniuError(err)
+	}
+
+	if u.config.Domain == "" {
+		// 文件已经上传成功，重试只会把同一个对象再上传一次，loadConfig 也已经要求 domain 必填，
+		// 这里只是兜底，所以标成终态错误
+		return "", newTerminalError(fmt.Errorf("qiniu.domain is required to build the public URL"))
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(u.config.Domain, "/"), ret.Key), nil
+}
+
+// classifyQiniuError 用七牛 SDK 返回的 ErrorInfo.Code（即 HTTP 状态码）区分可重试错误与终态错误
+func classifyQiniuError(err error) error {
+	wrapped := fmt.Errorf("failed to upload to qiniu: %v", err)
+
+	var qErr *storage.ErrorInfo
+	if errors.As(err, &qErr) {
+		return newStatusError(qErr.Code, wrapped, nil)
+	}
+	return wrapped
+}
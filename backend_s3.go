@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// s3Uploader 上传到 S3 或兼容 S3 协议的对象存储（如 Minio）
+type s3Uploader struct {
+	config S3Config
+	client *s3.Client
+}
+
+func newS3Uploader(cfg S3Config) (*s3Uploader, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1" // Minio 等自建存储通常不校验 region，给一个占位值即可
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion(region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load s3 config: %v", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &s3Uploader{config: cfg, client: client}, nil
+}
+
+// Upload 将文件上传至配置的 bucket，返回拼接出的公开访问 URL
+func (u *s3Uploader) Upload(ctx context.Context, imagePath string) (string, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+	defer file.Close()
+
+	key := filepath.Base(imagePath)
+	if u.config.KeyPrefix != "" {
+		key = strings.TrimSuffix(u.config.KeyPrefix, "/") + "/" + key
+	}
+
+	_, err = u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(u.config.Bucket),
+		Key:         aws.String(key),
+		Body:        file,
+		ContentType: aws.String(getContentType(imagePath)),
+	})
+	if err != nil {
+		return "", classifyS3Error(err)
+	}
+
+	return u.publicURL(key), nil
+}
+
+// classifyS3Error 从 smithy 的响应错误里取出 HTTP 状态码以区分可重试的网络类错误与终态错误（如鉴权失败）
+func classifyS3Error(err error) error {
+	wrapped := fmt.Errorf("failed to upload to s3: %v", err)
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		return newStatusError(respErr.Response.StatusCode, wrapped, respErr.Response.Header)
+	}
+	return wrapped
+}
+
+// publicURL 按 public_url_template 拼接可公开访问的地址，未配置时回退到标准 S3 虚拟主机风格地址
+func (u *s3Uploader) publicURL(key string) string {
+	tmpl := u.config.PublicURLTemplate
+	if tmpl == "" {
+		endpoint := strings.TrimPrefix(strings.TrimPrefix(u.config.Endpoint, "https://"), "http://")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("s3.%s.amazonaws.com", u.config.Region)
+		}
+		return fmt.Sprintf("https://%s.%s/%s", u.config.Bucket, endpoint, key)
+	}
+
+	replacer := strings.NewReplacer(
+		"{bucket}", u.config.Bucket,
+		"{region}", u.config.Region,
+		"{endpoint}", strings.TrimPrefix(strings.TrimPrefix(u.config.Endpoint, "https://"), "http://"),
+		"{key}", key,
+	)
+	return replacer.Replace(tmpl)
+}
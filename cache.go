@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cacheEntry 是一条已上传图片的记录：URL 用于内容去重，DHash 用于 safetyUploader 的近似查重
+type cacheEntry struct {
+	URL   string `json:"url"`
+	DHash uint64 `json:"dhash,omitempty"`
+}
+
+// uploadCache 是以文件内容 SHA-256 为 key 的本地缓存，
+// 用于避免重复上传 Typora 重复粘贴的相同截图，并为感知哈希查重提供已上传图片的指纹索引
+type uploadCache struct {
+	path string
+	mu   sync.Mutex
+	data map[string]cacheEntry
+}
+
+// newUploadCache 加载 path 处的缓存文件，文件不存在时返回空缓存。
+// 兼容只存了 hash -> url 的旧版缓存格式。
+func newUploadCache(path string) (*uploadCache, error) {
+	c := &uploadCache{path: path, data: make(map[string]cacheEntry)}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read cache file: %v", err)
+	}
+	if len(raw) == 0 {
+		return c, nil
+	}
+
+	if err := json.Unmarshal(raw, &c.data); err == nil {
+		return c, nil
+	}
+
+	var legacy map[string]string
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %v", err)
+	}
+	for hash, url := range legacy {
+		c.data[hash] = cacheEntry{URL: url}
+	}
+	return c, nil
+}
+
+func (c *uploadCache) get(hash string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.data[hash]
+	return entry.URL, ok
+}
+
+// setURL 记录 hash 对应的上传结果 URL，保留该 hash 已有的 DHash（如果有）
+func (c *uploadCache) setURL(hash, url string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.data[hash]
+	entry.URL = url
+	c.data[hash] = entry
+	return c.save()
+}
+
+// setDHash 记录 hash 对应的感知哈希指纹，保留该 hash 已有的 URL（如果有）
+func (c *uploadCache) setDHash(hash string, dhash uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.data[hash]
+	entry.DHash = dhash
+	c.data[hash] = entry
+	return c.save()
+}
+
+// nearestDuplicate 在已记录的指纹里查找与 dhash 汉明距离最小且不超过 threshold 的条目
+func (c *uploadCache) nearestDuplicate(dhash uint64, threshold int) (url string, distance int, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	best := -1
+	for _, entry := range c.data {
+		if entry.DHash == 0 {
+			continue
+		}
+		d := bits.OnesCount64(entry.DHash ^ dhash)
+		if best == -1 || d < best {
+			best = d
+			url = entry.URL
+		}
+	}
+	if best == -1 || best > threshold {
+		return "", 0, false
+	}
+	return url, best, true
+}
+
+// save 把缓存整体写回磁盘，调用前必须持有 c.mu
+func (c *uploadCache) save() error {
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cache dir: %v", err)
+		}
+	}
+	raw, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %v", err)
+	}
+	return os.WriteFile(c.path, raw, 0o644)
+}
+
+// cachingUploader 在上传前按内容哈希查询本地缓存，命中则直接返回缓存的 URL；
+// 未命中时以哈希作为远程文件名上传，并把结果写回缓存
+type cachingUploader struct {
+	next  Uploader
+	cache *uploadCache
+}
+
+func newCachingUploader(next Uploader, cache *uploadCache) *cachingUploader {
+	return &cachingUploader{next: next, cache: cache}
+}
+
+func (u *cachingUploader) Upload(ctx context.Context, path string) (string, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if url, ok := u.cache.get(hash); ok {
+		return url, nil
+	}
+
+	hashedPath, cleanup, err := withHashedFilename(path, hash)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	url, err := u.next.Upload(ctx, hashedPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.cache.setURL(hash, url); err != nil {
+		return "", fmt.Errorf("failed to update upload cache: %v", err)
+	}
+	return url, nil
+}
+
+// hashFile 计算文件内容的 SHA-256，返回十六进制字符串
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash image: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// withHashedFilename 在临时目录下准备一个文件名为 <hash><ext> 的副本，
+// 使底层 Uploader（按 filepath.Base 取名）上传时使用的远程文件名就是内容哈希。
+// 优先用硬链接避免整份拷贝，硬链接不可用时（例如跨盘符，或 Windows 默认权限下不允许符号链接）再回退到真正拷贝文件内容。
+func withHashedFilename(path, hash string) (string, func(), error) {
+	noop := func() {}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to resolve path: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "typora-upload-cache-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	linkPath := filepath.Join(dir, hash+filepath.Ext(path))
+	if err := os.Link(absPath, linkPath); err != nil {
+		if err := copyFile(absPath, linkPath); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("failed to prepare hashed filename: %v", err)
+		}
+	}
+	return linkPath, cleanup, nil
+}
+
+// copyFile 把 src 的内容完整拷贝到 dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// expandHome 将路径中开头的 "~" 展开为当前用户主目录
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
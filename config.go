@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 结构体，按 backend 字段选择使用哪一套上传配置
+type Config struct {
+	Backend string `yaml:"backend"`
+
+	// 以下字段为旧版扁平配置，仅在 backend 为空或为 "http" 且未填写 HTTP 小节时使用，
+	// 保留以兼容现有的 typora-upload-config.yaml
+	APIUrl   string `yaml:"api_url,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	HTTP  *HTTPConfig  `yaml:"http,omitempty"`
+	S3    *S3Config    `yaml:"s3,omitempty"`
+	Qiniu *QiniuConfig `yaml:"qiniu,omitempty"`
+	Git   *GitConfig   `yaml:"git,omitempty"`
+
+	Cache     *CacheConfig     `yaml:"cache,omitempty"`
+	Transform *TransformConfig `yaml:"transform,omitempty"`
+	Retry     *RetryConfig     `yaml:"retry,omitempty"`
+	Safety    *SafetyConfig    `yaml:"safety,omitempty"`
+}
+
+// SafetyConfig 控制上传前的客户端安全检查：近似查重与肤色/裸露启发式拦截
+type SafetyConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// DuplicateThreshold 是 dHash 汉明距离的阈值，<=0 表示关闭近似查重
+	DuplicateThreshold int `yaml:"duplicate_threshold"`
+	// OnDuplicate 是命中近似查重后的处理方式："warn"（默认，仅提示）或 "block"（拒绝上传）
+	OnDuplicate string `yaml:"on_duplicate"`
+
+	NudityCheck bool `yaml:"nudity_check"`
+	// NudityThreshold 是触发拒绝的肤色像素占比，未设置时默认 0.45
+	NudityThreshold float64 `yaml:"nudity_threshold"`
+}
+
+func (s *SafetyConfig) blocksOnDuplicate() bool {
+	return s != nil && s.OnDuplicate == "block"
+}
+
+func (s *SafetyConfig) nudityThresholdOrDefault() float64 {
+	if s != nil && s.NudityThreshold > 0 {
+		return s.NudityThreshold
+	}
+	return 0.45
+}
+
+// RetryConfig 控制上传失败后的重试策略，字段缺省时退回 retrySettings 里的默认值
+type RetryConfig struct {
+	// MaxRetries 为 nil 时使用默认值 3，显式设为 0 可关闭重试
+	MaxRetries       *int `yaml:"max_retries"`
+	InitialBackoffMs int  `yaml:"initial_backoff_ms"`
+	MaxBackoffMs     int  `yaml:"max_backoff_ms"`
+}
+
+// resolved 把 RetryConfig 落成带默认值的 retrySettings，nil 接收者返回纯默认值
+func (r *RetryConfig) resolved() retrySettings {
+	s := retrySettings{
+		maxRetries:     3,
+		initialBackoff: 500 * time.Millisecond,
+		maxBackoff:     10 * time.Second,
+	}
+	if r == nil {
+		return s
+	}
+	if r.MaxRetries != nil {
+		s.maxRetries = *r.MaxRetries
+	}
+	if r.InitialBackoffMs > 0 {
+		s.initialBackoff = time.Duration(r.InitialBackoffMs) * time.Millisecond
+	}
+	if r.MaxBackoffMs > 0 {
+		s.maxBackoff = time.Duration(r.MaxBackoffMs) * time.Millisecond
+	}
+	return s
+}
+
+// TransformConfig 控制上传前的客户端图片转码/压缩。转码总是重新编码为 WebP，
+// 这天然就会丢弃 EXIF 等元数据，因此这里没有单独的 strip_metadata 开关。
+type TransformConfig struct {
+	Format         string  `yaml:"format"` // 目前仅支持 "webp"
+	MaxWidth       int     `yaml:"max_width"`
+	Quality        float32 `yaml:"quality"`          // 0-100
+	SkipBelowBytes int64   `yaml:"skip_below_bytes"` // 小于该大小的文件跳过转码，0 表示不跳过
+}
+
+// CacheConfig 控制基于内容哈希的本地去重缓存
+type CacheConfig struct {
+	// Enabled 默认为 true（即使 cache 小节整体缺失），显式设为 false 可关闭
+	Enabled *bool  `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
+// enabled 返回缓存是否开启，nil 接收者或未设置 Enabled 时默认开启
+func (c *CacheConfig) enabled() bool {
+	if c == nil || c.Enabled == nil {
+		return true
+	}
+	return *c.Enabled
+}
+
+// path 返回缓存文件路径，未配置时使用 ~/.cache/typora-upload/index.json
+func (c *CacheConfig) path() string {
+	if c != nil && c.Path != "" {
+		return expandHome(c.Path)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", ".typora-upload-cache.json")
+	}
+	return filepath.Join(home, ".cache", "typora-upload", "index.json")
+}
+
+// HTTPConfig 通用 HTTP 表单上传后端配置
+type HTTPConfig struct {
+	APIUrl   string `yaml:"api_url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// S3Config S3/Minio 兼容对象存储后端配置
+type S3Config struct {
+	Bucket            string `yaml:"bucket"`
+	Region            string `yaml:"region"`
+	Endpoint          string `yaml:"endpoint"`
+	AccessKeyID       string `yaml:"access_key_id"`
+	SecretAccessKey   string `yaml:"secret_access_key"`
+	UsePathStyle      bool   `yaml:"use_path_style"`
+	KeyPrefix         string `yaml:"key_prefix"`
+	PublicURLTemplate string `yaml:"public_url_template"` // 支持 {bucket}/{region}/{endpoint}/{key} 占位符
+}
+
+// QiniuConfig 七牛云存储后端配置
+type QiniuConfig struct {
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	Bucket    string `yaml:"bucket"`
+	Domain    string `yaml:"domain"` // 绑定的公开访问域名，用于拼接返回的 URL
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// GitConfig Gitee/GitHub Contents API 后端配置
+type GitConfig struct {
+	Provider string `yaml:"provider"` // "gitee" 或 "github"
+	APIBase  string `yaml:"api_base"` // 自建 Gitea/GitHub Enterprise 时覆盖默认地址
+	Owner    string `yaml:"owner"`
+	Repo     string `yaml:"repo"`
+	Branch   string `yaml:"branch"`
+	Path     string `yaml:"path"` // 仓库内存放图片的目录前缀
+	Token    string `yaml:"token"`
+}
+
+// loadConfig 从YAML文件加载配置
+func loadConfig(configFile string) (Config, error) {
+	var config Config
+
+	// 读取配置文件
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// 解析YAML
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	if config.Backend == "" {
+		config.Backend = "http"
+	}
+
+	if err := config.validate(); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// validate 校验所选 backend 对应小节的必填字段
+func (c Config) validate() error {
+	switch c.Backend {
+	case "http":
+		http := c.HTTP
+		if http == nil {
+			http = &HTTPConfig{APIUrl: c.APIUrl, Username: c.Username, Password: c.Password}
+		}
+		if http.APIUrl == "" {
+			return fmt.Errorf("api_url is required in config")
+		}
+		if http.Username == "" || http.Password == "" {
+			return fmt.Errorf("username and password are required in config")
+		}
+	case "s3":
+		if c.S3 == nil {
+			return fmt.Errorf("s3 section is required when backend is \"s3\"")
+		}
+		if c.S3.Bucket == "" {
+			return fmt.Errorf("s3.bucket is required")
+		}
+		if c.S3.AccessKeyID == "" || c.S3.SecretAccessKey == "" {
+			return fmt.Errorf("s3.access_key_id and s3.secret_access_key are required")
+		}
+	case "qiniu":
+		if c.Qiniu == nil {
+			return fmt.Errorf("qiniu section is required when backend is \"qiniu\"")
+		}
+		if c.Qiniu.Bucket == "" {
+			return fmt.Errorf("qiniu.bucket is required")
+		}
+		if c.Qiniu.AccessKey == "" || c.Qiniu.SecretKey == "" {
+			return fmt.Errorf("qiniu.access_key and qiniu.secret_key are required")
+		}
+		if c.Qiniu.Domain == "" {
+			return fmt.Errorf("qiniu.domain is required")
+		}
+	case "git":
+		if c.Git == nil {
+			return fmt.Errorf("git section is required when backend is \"git\"")
+		}
+		if c.Git.Owner == "" || c.Git.Repo == "" {
+			return fmt.Errorf("git.owner and git.repo are required")
+		}
+		if c.Git.Token == "" {
+			return fmt.Errorf("git.token is required")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q (expected one of: http, s3, qiniu, git)", c.Backend)
+	}
+	return nil
+}
@@ -1,52 +1,32 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
-
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
-// Config 结构体
-type Config struct {
-	APIUrl   string `yaml:"api_url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-}
-
-// ApiResponse API响应结构体
-type ApiResponse struct {
-	Status int    `json:"status"`
-	Code   int    `json:"code"`
-	Msg    string `json:"msg"`
-	Data   string `json:"data"`
-}
-
-// 上传结果结构体
-type UploadResult struct {
-	FilePath string // 原始文件路径
-	ImageURL string // 上传后的URL
-	Error    error  // 错误信息
-}
-
 func main() {
 	// 解析命令行参数
 	var configFile string
+	var concurrency int
+	var timeout time.Duration
+	var rewrite bool
 	flag.StringVar(&configFile, "config", "typora-upload-config.yaml", "Path to config file")
+	flag.IntVar(&concurrency, "concurrency", runtime.NumCPU(), "Maximum number of concurrent uploads")
+	flag.DurationVar(&timeout, "timeout", 0, "Overall timeout for the upload run, e.g. 30s (0 = no timeout)")
+	flag.BoolVar(&rewrite, "rewrite", false, "Treat every argument as a Markdown file to scan and rewrite in place, regardless of its extension (default: auto-detect by .md extension)")
 	flag.Parse()
 
 	// 获取图片路径参数
 	args := flag.Args()
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Usage: typora-upload [--config=<path>] <image-path1> <image-path2> ...")
+		fmt.Fprintln(os.Stderr, "Usage: typora-upload [--config=<path>] [--concurrency=<n>] [--timeout=<duration>] [--rewrite] <image-path1> <image-path2> ... | <file.md> ...")
 		fmt.Fprintln(os.Stderr, "Default config file: typora-upload-config.yaml")
 		os.Exit(1)
 	}
@@ -58,178 +38,94 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 处理所有图片上传
-	results := uploadImages(config, args)
-
-	// 输出结果
-	hasError := false
-	for _, result := range results {
-		if result.Error != nil {
-			fmt.Fprintf(os.Stderr, "Upload failed for %s: %v\n", result.FilePath, result.Error)
-			hasError = true
-		} else {
-			// fmt.Printf("![](%s)\n", result.ImageURL)
-			fmt.Println(result.ImageURL)
-		}
-	}
-
-	if hasError {
+	// 根据 backend 构造对应的上传器
+	uploader, err := newUploader(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing uploader: %v\n", err)
 		os.Exit(1)
 	}
-}
 
-// loadConfig 从YAML文件加载配置
-func loadConfig(configFile string) (Config, error) {
-	var config Config
+	// 叠加带退避重试的包装，只重试实际的网络请求，避免重复转码/重复计算哈希
+	uploader = newRetryingUploader(uploader, config.Retry.resolved())
 
-	// 读取配置文件
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return config, fmt.Errorf("failed to read config file: %v", err)
+	// 叠加可选的客户端转码（WebP 压缩）
+	if config.Transform != nil {
+		uploader = newTranscodingUploader(uploader, *config.Transform)
 	}
 
-	// 解析YAML
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return config, fmt.Errorf("failed to parse config file: %v", err)
+	// safety 和 cache 共用同一份本地缓存来存取 URL 与 dHash 指纹
+	var cache *uploadCache
+	if config.Cache.enabled() || (config.Safety != nil && config.Safety.Enabled) {
+		cache, err = newUploadCache(config.Cache.path())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading upload cache: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// 验证必要配置
-	if config.APIUrl == "" {
-		return config, fmt.Errorf("api_url is required in config")
-	}
-	if config.Username == "" || config.Password == "" {
-		return config, fmt.Errorf("username and password are required in config")
+	// 叠加可选的近似查重 / 肤色裸露启发式拦截
+	if config.Safety != nil && config.Safety.Enabled {
+		uploader = newSafetyUploader(uploader, cache, *config.Safety)
 	}
 
-	return config, nil
-}
-
-// uploadImages 上传多个图片到服务器
-func uploadImages(config Config, imagePaths []string) []UploadResult {
-	results := make([]UploadResult, len(imagePaths))
-	ch := make(chan UploadResult, len(imagePaths))
-
-	// 并发上传所有图片
-	for _, path := range imagePaths {
-		go func(p string) {
-			url, err := uploadImage(config, p)
-			ch <- UploadResult{
-				FilePath: p,
-				ImageURL: url,
-				Error:    err,
-			}
-		}(path)
+	// 叠加基于内容哈希的本地去重缓存
+	if config.Cache.enabled() {
+		uploader = newCachingUploader(uploader, cache)
 	}
 
-	// 收集结果
-	for i := 0; i < len(imagePaths); i++ {
-		results[i] = <-ch
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	return results
-}
-
-// uploadImage 上传单个图片到服务器
-func uploadImage(config Config, imagePath string) (string, error) {
-	// 打开图片文件
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open image: %v", err)
+	// .md 文件走"扫描并原地改写"模式，其余参数按原有逻辑逐个上传；
+	// --rewrite 跳过扩展名判断，强制把所有参数都当作 Markdown 文件处理
+	var mdFiles, imagePaths []string
+	if rewrite {
+		mdFiles = args
+	} else {
+		mdFiles, imagePaths = splitMarkdownArgs(args)
 	}
-	defer file.Close()
 
-	// 创建multipart表单
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// 获取文件名
-	filename := filepath.Base(imagePath)
-
-	// 获取文件扩展名并设置Content-Type
-	contentType := getContentType(imagePath)
-
-	// 创建表单文件部分
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %v", err)
-	}
-
-	// 复制文件内容
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return "", fmt.Errorf("failed to copy file content: %v", err)
-	}
-
-	// 添加headers
-	headers := fmt.Sprintf("Content-Type: %s", contentType)
-	writer.WriteField("headers", headers)
-	writer.Close()
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", config.APIUrl, body)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// 设置Basic Auth和Content-Type
-	req.SetBasicAuth(config.Username, config.Password)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+	hasError := false
+	for _, mdFile := range mdFiles {
+		if err := rewriteMarkdownFile(ctx, uploader, concurrency, mdFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Rewrite failed for %s: %v\n", mdFile, err)
+			hasError = true
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Rewrote %s (backup saved to %s.bak)\n", mdFile, mdFile)
 	}
-	defer resp.Body.Close()
 
-	// 解析响应
-	return parseResponse(resp)
-}
+	// 处理所有图片上传
+	results := uploadImages(ctx, uploader, imagePaths, concurrency)
 
-// getContentType 根据文件扩展名获取Content-Type
-func getContentType(imagePath string) string {
-	ext := strings.ToLower(filepath.Ext(imagePath))
-	if ext != "" && ext[0] == '.' {
-		ext = ext[1:]
+	// 输出结果
+	for _, result := range results {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "Upload failed for %s: %v\n", result.FilePath, result.Error)
+			hasError = true
+		} else {
+			// fmt.Printf("![](%s)\n", result.ImageURL)
+			fmt.Println(result.ImageURL)
+		}
 	}
 
-	switch ext {
-	case "jpg", "jpeg":
-		return "image/jpeg"
-	case "png":
-		return "image/png"
-	case "gif":
-		return "image/gif"
-	case "svg":
-		return "image/svg+xml"
-	case "webp":
-		return "image/webp"
-	default:
-		return "image/" + ext
+	if hasError {
+		os.Exit(1)
 	}
 }
 
-// parseResponse 解析API响应
-func parseResponse(resp *http.Response) (string, error) {
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
-	}
-
-	// 解析JSON响应
-	var apiResp ApiResponse
-	err = json.Unmarshal(respBody, &apiResp)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	// 检查状态码
-	if apiResp.Status != 200 || apiResp.Code != 1 {
-		return "", fmt.Errorf("upload failed: %s", apiResp.Msg)
+// splitMarkdownArgs 把命令行参数分成 .md 文件（走改写模式）和其余图片路径（走原有上传模式）
+func splitMarkdownArgs(args []string) (mdFiles, imagePaths []string) {
+	for _, arg := range args {
+		if strings.EqualFold(filepath.Ext(arg), ".md") {
+			mdFiles = append(mdFiles, arg)
+		} else {
+			imagePaths = append(imagePaths, arg)
+		}
 	}
-
-	return apiResp.Data, nil
+	return mdFiles, imagePaths
 }
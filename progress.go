@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+var progressMu sync.Mutex
+
+// progressEvent 是输出到 stderr 的一行机器可读进度，stdout 只留给最终的图片 URL 供 Typora 解析
+type progressEvent struct {
+	File    string `json:"file"`
+	State   string `json:"state"` // "uploading" | "done" | "failed"
+	Attempt int    `json:"attempt"`
+}
+
+func emitProgress(file, state string, attempt int) {
+	data, err := json.Marshal(progressEvent{File: file, State: state, Attempt: attempt})
+	if err != nil {
+		return
+	}
+
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	os.Stderr.Write(append(data, '\n'))
+}
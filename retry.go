@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retrySettings 是 RetryConfig 落地后的重试参数
+type retrySettings struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// retryableError 包装一次上传失败的原因，并标注它是否值得重试
+type retryableError struct {
+	err        error
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// newStatusError 根据 HTTP 状态码判断一次请求失败是否值得重试：
+// 5xx 与 429（遵循 Retry-After）视为可重试的网络类错误，其余 4xx（如鉴权失败）视为终态错误
+func newStatusError(status int, err error, header http.Header) error {
+	retryable := status >= 500 || status == http.StatusTooManyRequests
+	var retryAfter time.Duration
+	if status == http.StatusTooManyRequests {
+		retryAfter = parseRetryAfter(header.Get("Retry-After"))
+	}
+	return &retryableError{err: err, retryable: retryable, retryAfter: retryAfter}
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数与 HTTP-date 两种格式
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// newTerminalError 把一次上传请求已经成功发出之后才发现的问题（响应解析失败、
+// 约定的字段没有按预期返回等）标记为终态错误。这类失败再重试一次结果不会不同，
+// 只会让同一个文件被重复上传/提交，所以必须明确标为不可重试，不能落入 isRetryable 的默认值
+func newTerminalError(err error) error {
+	return &retryableError{err: err, retryable: false}
+}
+
+// isRetryable 判断一个错误是否应当重试。显式标注的 retryableError 按其标记处理；
+// 其余错误（例如尚未分类的 SDK 内部错误）默认按可重试的网络类错误处理
+func isRetryable(err error) (retryable bool, retryAfter time.Duration) {
+	var re *retryableError
+	if errors.As(err, &re) {
+		return re.retryable, re.retryAfter
+	}
+	return true, 0
+}
+
+// retryingUploader 用指数退避加抖动包装底层 Uploader 的重试逻辑，并向 stderr 汇报每次尝试的进度
+type retryingUploader struct {
+	next     Uploader
+	settings retrySettings
+}
+
+func newRetryingUploader(next Uploader, settings retrySettings) *retryingUploader {
+	return &retryingUploader{next: next, settings: settings}
+}
+
+func (u *retryingUploader) Upload(ctx context.Context, path string) (string, error) {
+	backoff := u.settings.initialBackoff
+	progressFile := originalPathFromContext(ctx, path)
+
+	for attempt := 1; ; attempt++ {
+		emitProgress(progressFile, "uploading", attempt)
+
+		url, err := u.next.Upload(ctx, path)
+		if err == nil {
+			emitProgress(progressFile, "done", attempt)
+			return url, nil
+		}
+
+		retryable, retryAfter := isRetryable(err)
+		if !retryable || attempt > u.settings.maxRetries {
+			emitProgress(progressFile, "failed", attempt)
+			return "", err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1)) // 加入抖动，避免重试风暴
+		if wait > u.settings.maxBackoff {
+			wait = u.settings.maxBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			emitProgress(progressFile, "failed", attempt)
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > u.settings.maxBackoff {
+			backoff = u.settings.maxBackoff
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 匹配行内图片 ![alt](path "title")，以及 HTML <img src="..."> 和参考式链接定义 [ref]: path "title"
+// imageRefUseRe 匹配文档里实际把某个 label 当图片引用的地方 ![alt][label]，
+// 用来判断下面的 refDefRe 命中的定义到底是图片引用还是普通链接引用
+var (
+	inlineImageRe = regexp.MustCompile(`!\[[^\]]*\]\(\s*(\S+?)(?:\s+"[^"]*")?\s*\)`)
+	htmlImageRe   = regexp.MustCompile(`(?i)<img\s+[^>]*?src=["']([^"']+)["']`)
+	refDefRe      = regexp.MustCompile(`(?m)^\s*\[([^\]]+)\]:\s*(\S+)(?:\s+"[^"]*")?\s*$`)
+	imageRefUseRe = regexp.MustCompile(`!\[[^\]]*\]\[([^\]]+)\]`)
+)
+
+// imageOccurrence 是文档中一处对本地图片的引用：start/end 是 path 文本在文档里的字节范围
+type imageOccurrence struct {
+	start, end int
+	localPath  string
+}
+
+// rewriteMarkdownFile 扫描 mdPath 里引用的本地图片，逐个上传（文档内去重），
+// 并把 Markdown 原地改写为上传后的 URL，原文件备份为 <file>.bak
+func rewriteMarkdownFile(ctx context.Context, uploader Uploader, concurrency int, mdPath string) error {
+	data, err := os.ReadFile(mdPath)
+	if err != nil {
+		return fmt.Errorf("failed to read markdown file: %v", err)
+	}
+	content := string(data)
+	baseDir := filepath.Dir(mdPath)
+
+	occurrences := findLocalImageOccurrences(content, baseDir)
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	uniquePaths := uniqueSortedPaths(occurrences)
+	results := uploadImages(ctx, uploader, uniquePaths, concurrency)
+
+	urlByPath := make(map[string]string, len(results))
+	for _, r := range results {
+		if r.Error != nil {
+			return fmt.Errorf("failed to upload %s: %v", r.FilePath, r.Error)
+		}
+		urlByPath[r.FilePath] = r.ImageURL
+	}
+
+	// 从后往前替换，这样已处理片段的字节偏移不会被前面的替换打乱
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].start > occurrences[j].start })
+	for _, occ := range occurrences {
+		content = content[:occ.start] + urlByPath[occ.localPath] + content[occ.end:]
+	}
+
+	if err := os.WriteFile(mdPath+".bak", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write backup file: %v", err)
+	}
+	if err := os.WriteFile(mdPath, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write rewritten markdown: %v", err)
+	}
+	return nil
+}
+
+// findLocalImageOccurrences 收集行内图片、HTML <img> 以及参考式链接定义里指向本地文件的引用
+func findLocalImageOccurrences(content, baseDir string) []imageOccurrence {
+	var occurrences []imageOccurrence
+
+	for _, re := range []*regexp.Regexp{inlineImageRe, htmlImageRe} {
+		for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[2], m[3]
+			path := content[start:end]
+			if !isLocalImagePath(path) {
+				continue
+			}
+			occurrences = append(occurrences, imageOccurrence{
+				start:     start,
+				end:       end,
+				localPath: resolveMarkdownPath(baseDir, path),
+			})
+		}
+	}
+
+	usedLabels := usedImageRefLabels(content)
+	for _, m := range refDefRe.FindAllStringSubmatchIndex(content, -1) {
+		label := normalizeRefLabel(content[m[2]:m[3]])
+		if !usedLabels[label] {
+			continue
+		}
+		start, end := m[4], m[5]
+		path := content[start:end]
+		if !isLocalImagePath(path) {
+			continue
+		}
+		occurrences = append(occurrences, imageOccurrence{
+			start:     start,
+			end:       end,
+			localPath: resolveMarkdownPath(baseDir, path),
+		})
+	}
+
+	return occurrences
+}
+
+// usedImageRefLabels 收集文档里以 ![alt][label] 形式引用为图片的所有 label，
+// 只有出现在这个集合里的参考式定义才会被当成图片上传，避免误改普通链接
+func usedImageRefLabels(content string) map[string]bool {
+	labels := make(map[string]bool)
+	for _, m := range imageRefUseRe.FindAllStringSubmatchIndex(content, -1) {
+		labels[normalizeRefLabel(content[m[2]:m[3]])] = true
+	}
+	return labels
+}
+
+// normalizeRefLabel 按 Markdown 参考式链接的惯例做大小写不敏感、空白折叠的比较
+func normalizeRefLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// isLocalImagePath 排除远程 URL 和 data URI，只保留本地文件引用
+func isLocalImagePath(path string) bool {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasPrefix(lower, "http://"),
+		strings.HasPrefix(lower, "https://"),
+		strings.HasPrefix(lower, "//"),
+		strings.HasPrefix(lower, "data:"):
+		return false
+	default:
+		return true
+	}
+}
+
+// resolveMarkdownPath 把 Markdown 里写的相对路径相对于文档所在目录解析为绝对路径
+func resolveMarkdownPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// uniqueSortedPaths 对同一文档里重复引用的本地文件去重，排序只是为了让每次运行的上传顺序可复现
+func uniqueSortedPaths(occurrences []imageOccurrence) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, occ := range occurrences {
+		if seen[occ.localPath] {
+			continue
+		}
+		seen[occ.localPath] = true
+		paths = append(paths, occ.localPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// safetyUploader 在上传前做两项客户端检查：基于 dHash 的近似查重，以及可选的肤色/裸露启发式拦截。
+// 两者共用 cachingUploader 所使用的同一份本地缓存来存取已上传图片的指纹。
+type safetyUploader struct {
+	next   Uploader
+	cache  *uploadCache
+	config SafetyConfig
+}
+
+func newSafetyUploader(next Uploader, cache *uploadCache, config SafetyConfig) *safetyUploader {
+	return &safetyUploader{next: next, cache: cache, config: config}
+}
+
+func (u *safetyUploader) Upload(ctx context.Context, path string) (string, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".svg" {
+		return u.next.Upload(ctx, path)
+	}
+
+	img, err := decodeImage(path)
+	if err != nil {
+		// 无法解码（例如非图片格式）时不拦截，交由下游按原样处理
+		return u.next.Upload(ctx, path)
+	}
+
+	if u.config.NudityCheck {
+		ratio := skinToneRatio(img)
+		if ratio >= u.config.nudityThresholdOrDefault() {
+			return "", fmt.Errorf("safety check: rejected %s, skin-tone pixel ratio %.2f exceeds threshold", filepath.Base(path), ratio)
+		}
+	}
+
+	dhash := dHash(img)
+
+	if u.config.DuplicateThreshold > 0 {
+		if existingURL, distance, found := u.cache.nearestDuplicate(dhash, u.config.DuplicateThreshold); found {
+			msg := fmt.Sprintf("%s looks like a near-duplicate (hamming distance %d) of a previously uploaded image: %s", filepath.Base(path), distance, existingURL)
+			if u.config.blocksOnDuplicate() {
+				return "", fmt.Errorf("safety check: %s", msg)
+			}
+			fmt.Fprintln(os.Stderr, "Warning: "+msg)
+		}
+	}
+
+	url, err := u.next.Upload(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	if hash, herr := hashFile(path); herr == nil {
+		_ = u.cache.setDHash(hash, dhash)
+	}
+
+	return url, nil
+}
+
+// decodeImage 打开并解码 path 指向的 PNG/JPEG/GIF 文件
+func decodeImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %v", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return img, nil
+}
+
+// dHash 把图片缩小为 9x8 灰度图，按 bit i = (pixel[i] > pixel[i+1]) 生成一个 64 位感知哈希指纹
+func dHash(img image.Image) uint64 {
+	small := imaging.Resize(img, 9, 8, imaging.Lanczos)
+	gray := imaging.Grayscale(small)
+
+	var hash uint64
+	bit := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := gray.NRGBAAt(x, y).R
+			right := gray.NRGBAAt(x+1, y).R
+			if left > right {
+				hash |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+// skinToneRatio 用一个常见的 RGB 肤色经验规则粗略估计图片中肤色像素的占比，
+// 每隔几个像素采样一次以控制大图的计算量
+const skinToneSampleStride = 4
+
+func skinToneRatio(img image.Image) float64 {
+	bounds := img.Bounds()
+
+	var skin, total int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += skinToneSampleStride {
+		for x := bounds.Min.X; x < bounds.Max.X; x += skinToneSampleStride {
+			r, g, b, _ := img.At(x, y).RGBA()
+			// RGBA() 返回 16 位分量，缩回 8 位
+			r8, g8, b8 := float64(r>>8), float64(g>>8), float64(b>>8)
+			total++
+			if isSkinTone(r8, g8, b8) {
+				skin++
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(skin) / float64(total)
+}
+
+func isSkinTone(r, g, b float64) bool {
+	maxc := math.Max(r, math.Max(g, b))
+	minc := math.Min(r, math.Min(g, b))
+	return r > 95 && g > 40 && b > 20 &&
+		(maxc-minc) > 15 &&
+		math.Abs(r-g) > 15 &&
+		r > g && r > b
+}
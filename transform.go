@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+// transcodingUploader 在上传前将 PNG/JPEG/GIF 转码为 WebP，以减小粘贴截图的体积
+type transcodingUploader struct {
+	next   Uploader
+	config TransformConfig
+}
+
+func newTranscodingUploader(next Uploader, config TransformConfig) *transcodingUploader {
+	return &transcodingUploader{next: next, config: config}
+}
+
+func (u *transcodingUploader) Upload(ctx context.Context, path string) (string, error) {
+	if !u.shouldTranscode(path) {
+		return u.next.Upload(ctx, path)
+	}
+
+	data, err := u.transcode(path)
+	if err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	tempPath, cleanup, err := writeTempFile(data, base+".webp")
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	return u.next.Upload(ctx, tempPath)
+}
+
+// shouldTranscode 跳过 SVG 以及小于 SkipBelowBytes 的文件
+func (u *transcodingUploader) shouldTranscode(path string) bool {
+	if strings.ToLower(filepath.Ext(path)) == ".svg" {
+		return false
+	}
+
+	if u.config.SkipBelowBytes > 0 {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() < u.config.SkipBelowBytes {
+			return false
+		}
+	}
+
+	return true
+}
+
+// transcode 解码源图片，按需用 Lanczos 滤波器缩放，然后编码为 WebP
+func (u *transcodingUploader) transcode(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %v", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	if u.config.MaxWidth > 0 && img.Bounds().Dx() > u.config.MaxWidth {
+		img = imaging.Resize(img, u.config.MaxWidth, 0, imaging.Lanczos)
+	}
+
+	quality := u.config.Quality
+	if quality <= 0 {
+		quality = 82
+	}
+
+	var out bytes.Buffer
+	if err := webp.Encode(&out, img, &webp.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode webp: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// writeTempFile 把 data 写入一个以 filename 命名的临时文件，返回路径和清理函数
+func writeTempFile(data []byte, filename string) (string, func(), error) {
+	noop := func() {}
+
+	dir, err := os.MkdirTemp("", "typora-upload-transcode-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write temp file: %v", err)
+	}
+	return path, cleanup, nil
+}
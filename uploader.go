@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Uploader 是所有上传后端的统一接口
+type Uploader interface {
+	// Upload 上传 path 指向的本地文件，返回可公开访问的 URL
+	Upload(ctx context.Context, path string) (string, error)
+}
+
+// originalPathKey 是 context 里保存"用户原始传入路径"的 key。
+// cachingUploader / transcodingUploader 等装饰器会把 path 换成临时文件路径再传给下一层，
+// 装饰链内层（比如负责上报进度的 retryingUploader）因此看不到用户最初传入的路径，
+// 需要靠 context 把这个原始路径一路带下去
+type originalPathKey struct{}
+
+// withOriginalPath 记录本次 Upload 调用时用户传入的原始路径，供装饰链下游在上报进度等场景使用
+func withOriginalPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, originalPathKey{}, path)
+}
+
+// originalPathFromContext 取出 withOriginalPath 记录的原始路径，没有记录时回退为 fallback
+func originalPathFromContext(ctx context.Context, fallback string) string {
+	if v, ok := ctx.Value(originalPathKey{}).(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// 上传结果结构体
+type UploadResult struct {
+	FilePath string // 原始文件路径
+	ImageURL string // 上传后的URL
+	Error    error  // 错误信息
+}
+
+// newUploader 根据 config.Backend 构造对应的 Uploader 实现
+func newUploader(config Config) (Uploader, error) {
+	switch config.Backend {
+	case "http":
+		http := config.HTTP
+		if http == nil {
+			http = &HTTPConfig{APIUrl: config.APIUrl, Username: config.Username, Password: config.Password}
+		}
+		return newHTTPUploader(*http), nil
+	case "s3":
+		return newS3Uploader(*config.S3)
+	case "qiniu":
+		return newQiniuUploader(*config.Qiniu), nil
+	case "git":
+		return newGitUploader(*config.Git), nil
+	default:
+		// loadConfig 已经校验过 backend，正常不会走到这里
+		return nil, &unknownBackendError{backend: config.Backend}
+	}
+}
+
+type unknownBackendError struct {
+	backend string
+}
+
+func (e *unknownBackendError) Error() string {
+	return "unknown backend: " + e.backend
+}
+
+// uploadImages 用一个容量为 concurrency 的工作池并发上传多个图片，避免一次性打开过多 socket
+func uploadImages(ctx context.Context, uploader Uploader, imagePaths []string, concurrency int) []UploadResult {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]UploadResult, len(imagePaths))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, path := range imagePaths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, p string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			url, err := uploader.Upload(withOriginalPath(ctx, p), p)
+			results[i] = UploadResult{
+				FilePath: p,
+				ImageURL: url,
+				Error:    err,
+			}
+		}(i, path)
+	}
+
+	wg.Wait()
+	return results
+}